@@ -3,76 +3,671 @@ package main
 import (
 	"bytes"
 	"compress/flate"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"go/build"
 	"go/format"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"text/template"
 	"unicode"
 )
 
 var (
-	out      = flag.String("out", "data.go", "Output file")
-	prefix   = flag.String("prefix", "", "Prefix to strip from filenames")
-	suffix   = flag.String("suffix", "", "Suffix to strip from filenames")
-	compress = flag.Bool("compress", true, "Use compression")
-	override = flag.String("pkg", "", "Override package name")
-	gofmt    = flag.Bool("format", true, "Run output through gofmt")
-	level    = flag.Int("level", flate.DefaultCompression, "Compression Level")
-	force    = flag.Bool("force", false, "Force overwrite of existing file")
+	out         = flag.String("out", "data.go", "Output file")
+	prefix      = flag.String("prefix", "", "Prefix to strip from filenames")
+	suffix      = flag.String("suffix", "", "Suffix to strip from filenames")
+	compress    = flag.Bool("compress", true, "Use compression (equivalent to -codec=flate; ignored if -codec is set)")
+	override    = flag.String("pkg", "", "Override package name")
+	gofmt       = flag.Bool("format", true, "Run output through gofmt")
+	level       = flag.Int("level", flate.DefaultCompression, "Compression Level")
+	force       = flag.Bool("force", false, "Force overwrite of existing file")
+	fsVar       = flag.String("fs", "", "Also generate a package-level io/fs.FS variable with this name")
+	hashFlag    = flag.Bool("hash", false, "Generate per-file and bundle SHA-256 content hashes (golang.org/x/mod dirhash h1 scheme) plus a runtime Verify check per file")
+	bytesFlag   = flag.Bool("bytes", true, "Also generate a func Xxx() ([]byte, error) accessor per file, in addition to the streaming XxxReader/XxxSize form")
+	maxFileSize = flag.Int64("max-file-size", 0, "When >0, split per-file accessors across data_1.go, data_2.go, ... once their combined raw literal size would exceed this many bytes; shared Codec/fs.FS/hash glue stays in -out")
+	includes    stringList
+	excludes    stringList
+	codecArg    stringList
 )
 
+func init() {
+	flag.Var(&includes, "include", "Glob/doublestar pattern a file must match to be embedded (repeatable); matched against the path after -prefix/-suffix stripping")
+	flag.Var(&excludes, "exclude", "Glob/doublestar pattern that excludes a file from being embedded (repeatable); matched against the path after -prefix/-suffix stripping")
+	flag.Var(&codecArg, "codec", "Codec to try when packing each file (repeatable): none, flate, gzip. \"none\" is always tried; the smallest result wins per file. zstd, snappy, s2 and brotli are recognized Codec IDs that a consumer can decode via RegisterCodec, but this generator has no dependency-free encoder for them")
+}
+
+// stringList is a flag.Value that accumulates repeated -flag=value
+// occurrences into a slice.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
 const tmplText = `package {{ .Package }}
 import (
-	"bytes"
 	"strings"
 	"io"
-{{ if .Compress }}
+	"fmt"
+{{ if .Bytes }}
+	"bytes"
+{{ end }}
+{{ if .UsesFlate }}
 	"compress/flate"
 {{ end }}
+{{ if .UsesGzip }}
+	"compress/gzip"
+{{ end }}
+{{ if .FSVar }}
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+{{ end }}
+{{ if .Hash }}
+	"crypto/sha256"
+	"encoding/hex"
+{{ end }}
 )
 {{ range .Files }}
+// {{.Func}}Reader decodes the embedded data on demand, without buffering
+// the whole file in memory.
+func {{.Func}}Reader() (io.ReadCloser, error) {
+	in := strings.NewReader("{{.Raw}}")
+	r, err := decode({{.Codec}}, in)
+	if err != nil {
+		return nil, err
+	}
+	return newReadCloser(r), nil
+}
+
+// {{.Func}}Size is the file's uncompressed size, captured at generation time.
+func {{.Func}}Size() int64 {
+	return {{.Size}}
+}
+{{ if $.Bytes }}
 func {{.Func}} () ([]byte, error) {
-	data := "{{.Raw}}"
-	in := strings.NewReader(data)
+	r, err := {{.Func}}Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
 	out := new(bytes.Buffer)
-	{{ if .Compress }}
-	r := flate.NewReader(in)
-	if _, err := io.Copy(out,r) ; err != nil {
+	if _, err := io.Copy(out, r); err != nil {
 		return nil, err
 	}
-	if err := r.Close(); err != nil {
+	return out.Bytes(), nil
+}
+{{ end }}
+{{ if $.Hash }}
+func {{.Func}}Verify() error {
+	r, err := {{.Func}}Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if got, want := hex.EncodeToString(h.Sum(nil)), {{ printf "%q" .Sha256 }}; got != want {
+		return fmt.Errorf("datacode: checksum mismatch for %q: got %s, want %s", {{ printf "%q" .Name }}, got, want)
+	}
+	return nil
+}
+{{ end }}
+{{ end }}
+{{ template "sharedGlue" . }}
+`
+
+// glueTmplText holds everything shared across shards when a bundle is split
+// by -max-file-size: the Codec/decode machinery and (if enabled) the hash
+// registry and fs.FS glue. It never contains a per-file accessor, so it
+// stays valid regardless of how the files are bucketed into shards.
+const glueTmplText = `package {{ .Package }}
+import (
+	"io"
+	"fmt"
+{{ if .UsesFlate }}
+	"compress/flate"
+{{ end }}
+{{ if .UsesGzip }}
+	"compress/gzip"
+{{ end }}
+{{ if .FSVar }}
+	"strings"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+{{ end }}
+)
+{{ template "sharedGlue" . }}
+`
+
+// sharedTmplText defines "sharedGlue", the Codec/decode/readCloser
+// machinery plus (if enabled) the hash registry and fs.FS glue: the block
+// that's identical between tmplText and glueTmplText regardless of whether
+// a bundle is emitted as one file or split by -max-file-size. tmplText and
+// glueTmplText each render it with {{ template "sharedGlue" . }} instead of
+// repeating it.
+const sharedTmplText = `{{ define "sharedGlue" }}
+// Codec identifies the compression scheme a given embedded file was packed
+// with.
+type Codec byte
+
+const (
+	CodecNone Codec = iota
+	CodecFlate
+	CodecGzip
+	CodecZstd
+	CodecSnappy
+	CodecS2
+	CodecBrotli
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecNone:
+		return "none"
+	case CodecFlate:
+		return "flate"
+	case CodecGzip:
+		return "gzip"
+	case CodecZstd:
+		return "zstd"
+	case CodecSnappy:
+		return "snappy"
+	case CodecS2:
+		return "s2"
+	case CodecBrotli:
+		return "brotli"
+	default:
+		return "unknown"
+	}
+}
+
+// Decoder turns the raw embedded bytes for a Codec back into a byte stream.
+type Decoder func(io.Reader) (io.Reader, error)
+
+var codecs = map[Codec]Decoder{
+	CodecNone: func(r io.Reader) (io.Reader, error) { return r, nil },
+{{ if .UsesFlate }}
+	CodecFlate: func(r io.Reader) (io.Reader, error) { return flate.NewReader(r), nil },
+{{ end }}
+{{ if .UsesGzip }}
+	CodecGzip: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+{{ end }}
+}
+
+// RegisterCodec wires a Decoder into this package for a Codec it could not
+// generate an encoder for itself (for example CodecZstd), without
+// regenerating this file.
+func RegisterCodec(c Codec, dec Decoder) {
+	codecs[c] = dec
+}
+
+func decode(c Codec, r io.Reader) (io.Reader, error) {
+	dec, ok := codecs[c]
+	if !ok {
+		return nil, fmt.Errorf("datacode: no decoder registered for codec %v", c)
+	}
+	return dec(r)
+}
+
+// readCloser adapts a decoded io.Reader into an io.ReadCloser, closing the
+// underlying reader on Close if it supports that itself.
+type readCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (rc *readCloser) Close() error {
+	if rc.closer == nil {
+		return nil
+	}
+	return rc.closer.Close()
+}
+
+func newReadCloser(r io.Reader) io.ReadCloser {
+	c, _ := r.(io.Closer)
+	return &readCloser{Reader: r, closer: c}
+}
+{{ if .Hash }}
+var hashes = map[string]string{
+{{ range .Files }}	{{ printf "%q" .Name }}: {{ printf "%q" .Sha256 }},
+{{ end }}}
+
+// Sum is the content hash of the whole embedded bundle, computed the same
+// way as golang.org/x/mod/sumdb/dirhash.Hash1.
+func Sum() string {
+	return {{ printf "%q" .Sum }}
+}
+
+// FileSum returns the per-file SHA-256 hash recorded at generation time, or
+// the empty string if name was not embedded.
+func FileSum(name string) string {
+	return hashes[name]
+}
+{{ end }}
+{{ if .FSVar }}
+type {{.FSVar}}fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi *{{.FSVar}}fileInfo) Name() string               { return fi.name }
+func (fi *{{.FSVar}}fileInfo) Size() int64                { return fi.size }
+func (fi *{{.FSVar}}fileInfo) Mode() fs.FileMode          { return fi.mode }
+func (fi *{{.FSVar}}fileInfo) ModTime() time.Time         { return fi.modTime }
+func (fi *{{.FSVar}}fileInfo) IsDir() bool                { return fi.mode.IsDir() }
+func (fi *{{.FSVar}}fileInfo) Sys() interface{}           { return nil }
+func (fi *{{.FSVar}}fileInfo) Type() fs.FileMode          { return fi.mode.Type() }
+func (fi *{{.FSVar}}fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+type {{.FSVar}}file struct {
+	*{{.FSVar}}fileInfo
+	io.ReadCloser
+}
+
+func (f *{{.FSVar}}file) Stat() (fs.FileInfo, error) { return f.{{.FSVar}}fileInfo, nil }
+
+type {{.FSVar}}entry struct {
+	info {{.FSVar}}fileInfo
+	open func() (io.ReadCloser, error)
+}
+
+var {{.FSVar}}entries = map[string]*{{.FSVar}}entry{
+{{ range .Files }}	{{ printf "%q" .Name }}: &{{$.FSVar}}entry{
+		info: {{$.FSVar}}fileInfo{
+			name:    {{ printf "%q" .BaseName }},
+			size:    {{.Size}},
+			modTime: time.Unix({{.ModTime}}, 0),
+		},
+		open: {{.Func}}Reader,
+	},
+{{ end }}}
+
+func {{.FSVar}}isDir(name string) bool {
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	for n := range {{.FSVar}}entries {
+		if strings.HasPrefix(n, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type {{.FSVar}}FS struct{}
+
+func (fsys {{.FSVar}}FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := {{.FSVar}}entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	r, err := e.open()
+	if err != nil {
 		return nil, err
 	}
-	{{ else }}
-	if _, err := io.Copy(out, in) ; err != nil {
+	return &{{.FSVar}}file{ {{.FSVar}}fileInfo: &e.info, ReadCloser: r}, nil
+}
+
+func (fsys {{.FSVar}}FS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := {{.FSVar}}entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+	}
+	r, err := e.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (fsys {{.FSVar}}FS) Stat(name string) (fs.FileInfo, error) {
+	if name == "." {
+		return &{{.FSVar}}fileInfo{name: ".", mode: fs.ModeDir}, nil
+	}
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if e, ok := {{.FSVar}}entries[name]; ok {
+		return &e.info, nil
+	}
+	if {{.FSVar}}isDir(name) {
+		return &{{.FSVar}}fileInfo{name: path.Base(name), mode: fs.ModeDir}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (fsys {{.FSVar}}FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." && !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	seen := make(map[string]fs.DirEntry)
+	for n, e := range {{.FSVar}}entries {
+		if !strings.HasPrefix(n, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(n, prefix)
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			dirName := rest[:i]
+			if _, ok := seen[dirName]; !ok {
+				seen[dirName] = &{{.FSVar}}fileInfo{name: dirName, mode: fs.ModeDir}
+			}
+			continue
+		}
+		seen[rest] = &e.info
+	}
+	if len(seen) == 0 && name != "." && !{{.FSVar}}isDir(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	list := make([]fs.DirEntry, 0, len(seen))
+	for _, e := range seen {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+func (fsys {{.FSVar}}FS) Glob(pattern string) ([]string, error) {
+	var matches []string
+	for n := range {{.FSVar}}entries {
+		ok, err := path.Match(pattern, n)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, n)
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func (fsys {{.FSVar}}FS) Sub(dir string) (fs.FS, error) {
+	if dir == "." {
+		return fsys, nil
+	}
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	return {{.FSVar}}subFS(dir), nil
+}
+
+// {{.FSVar}}subFS is the fs.FS returned by {{.FSVar}}FS.Sub. It rewrites
+// every path relative to the directory it was rooted at before delegating
+// back to {{.FSVar}}, rather than wrapping itself through fs.Sub (which
+// would type-assert this package's SubFS implementation and call straight
+// back into Sub, recursing forever).
+type {{.FSVar}}subFS string
+
+func (dir {{.FSVar}}subFS) fullName(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return string(dir), nil
+	}
+	return string(dir) + "/" + name, nil
+}
+
+func (dir {{.FSVar}}subFS) shorten(name string) (string, bool) {
+	if name == string(dir) {
+		return ".", true
+	}
+	if rest := strings.TrimPrefix(name, string(dir)+"/"); rest != name {
+		return rest, true
+	}
+	return "", false
+}
+
+func (dir {{.FSVar}}subFS) fixErr(err error) error {
+	if pe, ok := err.(*fs.PathError); ok {
+		if short, ok := dir.shorten(pe.Path); ok {
+			pe.Path = short
+		}
+	}
+	return err
+}
+
+func (dir {{.FSVar}}subFS) Open(name string) (fs.File, error) {
+	full, err := dir.fullName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := {{.FSVar}}.Open(full)
+	return f, dir.fixErr(err)
+}
+
+func (dir {{.FSVar}}subFS) ReadFile(name string) ([]byte, error) {
+	full, err := dir.fullName("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := {{.FSVar}}.ReadFile(full)
+	return data, dir.fixErr(err)
+}
+
+func (dir {{.FSVar}}subFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := dir.fullName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := {{.FSVar}}.Stat(full)
+	return fi, dir.fixErr(err)
+}
+
+func (dir {{.FSVar}}subFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := dir.fullName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := {{.FSVar}}.ReadDir(full)
+	return entries, dir.fixErr(err)
+}
+
+func (dir {{.FSVar}}subFS) Glob(pattern string) ([]string, error) {
+	matches, err := {{.FSVar}}.Glob(string(dir) + "/" + pattern)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if short, ok := dir.shorten(m); ok {
+			out = append(out, short)
+		}
+	}
+	return out, nil
+}
+
+func (dir {{.FSVar}}subFS) Sub(sub string) (fs.FS, error) {
+	if sub == "." {
+		return dir, nil
+	}
+	full, err := dir.fullName("sub", sub)
+	if err != nil {
+		return nil, err
+	}
+	return {{.FSVar}}subFS(full), nil
+}
+
+var {{.FSVar}} {{.FSVar}}FS
+{{ end }}
+{{ end }}
+`
+
+var sharedDefs = template.Must(template.New("sharedDefs").Parse(sharedTmplText))
+var tmpl = template.Must(sharedDefs.New("output").Parse(tmplText))
+var glueTmpl = template.Must(sharedDefs.New("glue").Parse(glueTmplText))
+
+// shardTmplText holds only the per-file accessors, so it can be rendered
+// once per shard against a shardData whose Files is a subset of the bundle.
+const shardTmplText = `package {{ .Package }}
+import (
+	"strings"
+	"io"
+{{ if .Bytes }}
+	"bytes"
+{{ end }}
+{{ if .Hash }}
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+{{ end }}
+)
+{{ range .Files }}
+// {{.Func}}Reader decodes the embedded data on demand, without buffering
+// the whole file in memory.
+func {{.Func}}Reader() (io.ReadCloser, error) {
+	in := strings.NewReader("{{.Raw}}")
+	r, err := decode({{.Codec}}, in)
+	if err != nil {
+		return nil, err
+	}
+	return newReadCloser(r), nil
+}
+
+// {{.Func}}Size is the file's uncompressed size, captured at generation time.
+func {{.Func}}Size() int64 {
+	return {{.Size}}
+}
+{{ if $.Bytes }}
+func {{.Func}} () ([]byte, error) {
+	r, err := {{.Func}}Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out := new(bytes.Buffer)
+	if _, err := io.Copy(out, r); err != nil {
 		return nil, err
 	}
-	{{ end }}
 	return out.Bytes(), nil
 }
 {{ end }}
+{{ if $.Hash }}
+func {{.Func}}Verify() error {
+	r, err := {{.Func}}Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if got, want := hex.EncodeToString(h.Sum(nil)), {{ printf "%q" .Sha256 }}; got != want {
+		return fmt.Errorf("datacode: checksum mismatch for %q: got %s, want %s", {{ printf "%q" .Name }}, got, want)
+	}
+	return nil
+}
+{{ end }}
+{{ end }}
 `
 
-var tmpl = template.Must(template.New("output").Parse(tmplText))
+var shardTmpl = template.Must(template.New("shard").Parse(shardTmplText))
+
+// shardData is the template context for one shard: the shared config plus
+// the slice of files assigned to this shard. Its Files field shadows
+// config's Files method so `{{ range .Files }}` iterates only this shard.
+type shardData struct {
+	*config
+	Files []file
+}
 
-func doIt(c *config, gofmt bool) ([]byte, error) {
+type genOutput struct {
+	Name string
+	Data []byte
+}
+
+func render(t *template.Template, data interface{}, gofmt bool) ([]byte, error) {
 	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	if !gofmt {
+		return buf.Bytes(), nil
+	}
+	return format.Source(buf.Bytes())
+}
+
+// doIt renders the bundle. With -max-file-size unset (or too small a bundle
+// to need it), it produces a single file identical to the pre-sharding
+// output. Otherwise it produces a shared glue file (named Out) plus one
+// shard file per group of files.
+func doIt(c *config, gofmt bool) ([]genOutput, error) {
+	if c.MaxFileSize <= 0 {
+		data, err := render(tmpl, c, gofmt)
+		if err != nil {
+			return nil, err
+		}
+		return []genOutput{{Name: c.Out, Data: data}}, nil
+	}
 
-	if err := tmpl.Execute(buf, c); err != nil {
+	shards, err := c.shardFiles()
+	if err != nil {
 		return nil, err
 	}
-	data := buf.Bytes()
+	if len(shards) <= 1 {
+		data, err := render(tmpl, c, gofmt)
+		if err != nil {
+			return nil, err
+		}
+		return []genOutput{{Name: c.Out, Data: data}}, nil
+	}
 
-	if !gofmt {
-		return data, nil
+	outs := make([]genOutput, 0, len(shards)+1)
+
+	glueData, err := render(glueTmpl, c, gofmt)
+	if err != nil {
+		return nil, err
 	}
-	return format.Source(data)
+	outs = append(outs, genOutput{Name: c.Out, Data: glueData})
+
+	dir := filepath.Dir(c.Out)
+	base := strings.TrimSuffix(filepath.Base(c.Out), filepath.Ext(c.Out))
+	ext := filepath.Ext(c.Out)
+	for i, files := range shards {
+		data, err := render(shardTmpl, &shardData{config: c, Files: files}, gofmt)
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.Join(dir, fmt.Sprintf("%s_%d%s", base, i+1, ext))
+		outs = append(outs, genOutput{Name: name, Data: data})
+	}
+	return outs, nil
 }
 
 func main() {
@@ -83,10 +678,6 @@ func main() {
 		os.Exit(-1)
 	}
 
-	if !*force && exists(*out) {
-		log.Fatalf("Can't output, %q exists (use -f to override)", *out)
-	}
-
 	p := *override
 	if len(p) == 0 {
 		odir := filepath.Dir(*out)
@@ -104,16 +695,38 @@ func main() {
 		Args:          flag.Args(),
 		Compress:      *compress,
 		CompressLevel: *level,
+		FSVar:         *fsVar,
+		Include:       includes,
+		Exclude:       excludes,
+		Hash:          *hashFlag,
+		Codecs:        codecArg,
+		Bytes:         *bytesFlag,
+		Out:           *out,
+		MaxFileSize:   *maxFileSize,
 	}
 
-	data, err := doIt(c, *gofmt)
-	if err != nil {
+	if _, err := c.codecKinds(); err != nil {
 		log.Fatal(err)
 	}
 
-	if err := ioutil.WriteFile(*out, data, 0644); err != nil {
+	outs, err := doIt(c, *gofmt)
+	if err != nil {
 		log.Fatal(err)
 	}
+
+	if !*force {
+		for _, o := range outs {
+			if exists(o.Name) {
+				log.Fatalf("Can't output, %q exists (use -f to override)", o.Name)
+			}
+		}
+	}
+
+	for _, o := range outs {
+		if err := ioutil.WriteFile(o.Name, o.Data, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
 
 func exists(path string) bool {
@@ -135,32 +748,350 @@ type config struct {
 	Args           []string
 	Compress       bool
 	CompressLevel  int
+	FSVar          string
+	Include        []string
+	Exclude        []string
+	Hash           bool
+	Codecs         []string
+	Bytes          bool
+	Out            string
+	MaxFileSize    int64
 }
 
 type file struct {
 	*config
-	Path string
+	Path   string
+	info   os.FileInfo
+	packed *packedResult
+	raw    *string
 }
 
 func (c *config) Files() ([]file, error) {
-	out := make([]file, 0, len(c.Args))
-	for _, arg := range c.Args {
-		out = append(out, file{Path: arg, config: c})
+	paths, err := c.expandArgs()
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files to embed: %v matched nothing after directory/glob expansion and -include/-exclude filtering", c.Args)
+	}
+
+	out := make([]file, 0, len(paths))
+	for _, p := range paths {
+		out = append(out, file{Path: p, config: c})
 	}
 	exists := make(map[string]bool, len(out))
+	names := make(map[string]bool, len(out))
 	for _, f := range out {
 		fname := f.Func()
 		if exists[fname] {
 			return nil, fmt.Errorf("duplicate function detected: %s", fname)
 		}
 		exists[fname] = true
+
+		if c.FSVar != "" || c.Hash {
+			name, err := f.Name()
+			if err != nil {
+				return nil, err
+			}
+			if names[name] {
+				return nil, fmt.Errorf("duplicate fs.FS name detected: %s", name)
+			}
+			names[name] = true
+		}
+	}
+	return out, nil
+}
+
+// shardFiles groups the bundle's files into shards whose raw literal content
+// stays within MaxFileSize, in the order c.Files() returns them. It never
+// splits a shard that holds only a single file, even if that file alone
+// exceeds MaxFileSize. A bundle that doesn't need splitting comes back as a
+// single shard.
+func (c *config) shardFiles() ([][]file, error) {
+	files, err := c.Files()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return [][]file{files}, nil
+	}
+
+	var shards [][]file
+	cur := []file{}
+	var curSize int64
+	for _, f := range files {
+		raw, err := f.Raw()
+		if err != nil {
+			return nil, err
+		}
+		size := int64(len(raw))
+		if len(cur) > 0 && curSize+size > c.MaxFileSize {
+			shards = append(shards, cur)
+			cur = []file{}
+			curSize = 0
+		}
+		cur = append(cur, f)
+		curSize += size
+	}
+	shards = append(shards, cur)
+	return shards, nil
+}
+
+// expandArgs turns the raw command-line arguments into a flat, filtered list
+// of file paths: directories are walked recursively, glob/doublestar
+// patterns are expanded against the filesystem, and anything else is taken
+// as a literal path.
+func (c *config) expandArgs() ([]string, error) {
+	var paths []string
+	for _, arg := range c.Args {
+		switch {
+		case hasMeta(arg):
+			matches, err := expandGlob(arg)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, matches...)
+		default:
+			info, err := os.Stat(arg)
+			if err != nil {
+				return nil, err
+			}
+			if info.IsDir() {
+				files, err := expandDir(arg)
+				if err != nil {
+					return nil, err
+				}
+				paths = append(paths, files...)
+			} else {
+				paths = append(paths, arg)
+			}
+		}
+	}
+	return c.filterPaths(paths)
+}
+
+// filterPaths applies -include/-exclude, matched against each path after
+// stripping Prefix/Suffix the same way Func and Name do.
+func (c *config) filterPaths(paths []string) ([]string, error) {
+	if len(c.Include) == 0 && len(c.Exclude) == 0 {
+		return paths, nil
+	}
+
+	include, err := compilePatterns(c.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compilePatterns(c.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		name := c.strip(p)
+		if len(include) > 0 && !matchAny(include, name) {
+			continue
+		}
+		if matchAny(exclude, name) {
+			continue
+		}
+		out = append(out, p)
 	}
 	return out, nil
 }
 
+// strip removes Prefix/Suffix from a path, the way Func and Name do.
+func (c *config) strip(p string) string {
+	p = strings.TrimPrefix(p, c.Prefix)
+	p = strings.TrimSuffix(p, c.Suffix)
+	return filepath.ToSlash(p)
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pat := range patterns {
+		re, err := globToRegexp(pat)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern %q: %w", pat, err)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// expandDir walks a directory recursively, returning every regular file
+// beneath it in sorted order.
+func expandDir(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// expandGlob resolves a **-aware glob pattern against the filesystem,
+// walking from the longest meta-character-free directory prefix of the
+// pattern.
+func expandGlob(pattern string) ([]string, error) {
+	re, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %w", pattern, err)
+	}
+
+	var matches []string
+	root := globBase(pattern)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if re.MatchString(filepath.ToSlash(p)) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// globBase returns the leading run of pattern segments that contain no glob
+// metacharacters, i.e. the deepest directory we can safely start a
+// filepath.WalkDir from.
+func globBase(pattern string) string {
+	segs := strings.Split(filepath.ToSlash(pattern), "/")
+	var base []string
+	for _, s := range segs {
+		if hasMeta(s) {
+			break
+		}
+		base = append(base, s)
+	}
+	if len(base) == 0 {
+		return "."
+	}
+	return strings.Join(base, "/")
+}
+
+// globToRegexp compiles a doublestar-style glob pattern ("**/*.tmpl") into a
+// regexp matched against slash-separated paths. "**" matches zero or more
+// path segments, "*" matches within a single segment, "?" matches a single
+// rune within a segment, and "[...]" (or "[!...]"/"[^...]" to negate)
+// matches one character from a class within a segment.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	const (
+		doubleStarSlash = "\x00doublestarslash\x00"
+		doubleStar      = "\x00doublestar\x00"
+		star            = "\x00star\x00"
+		quest           = "\x00quest\x00"
+	)
+
+	p := filepath.ToSlash(pattern)
+
+	var classes []string
+	p, err := extractClasses(p, &classes)
+	if err != nil {
+		return nil, err
+	}
+
+	p = strings.ReplaceAll(p, "**/", doubleStarSlash)
+	p = strings.ReplaceAll(p, "**", doubleStar)
+	p = strings.ReplaceAll(p, "*", star)
+	p = strings.ReplaceAll(p, "?", quest)
+
+	p = regexp.QuoteMeta(p)
+
+	p = strings.ReplaceAll(p, doubleStarSlash, "(?:.*/)?")
+	p = strings.ReplaceAll(p, doubleStar, ".*")
+	p = strings.ReplaceAll(p, star, "[^/]*")
+	p = strings.ReplaceAll(p, quest, "[^/]")
+	for i, class := range classes {
+		p = strings.ReplaceAll(p, classPlaceholder(i), class)
+	}
+
+	return regexp.Compile("^" + p + "$")
+}
+
+func classPlaceholder(i int) string {
+	return fmt.Sprintf("\x00class%d\x00", i)
+}
+
+// extractClasses pulls each "[...]" character class out of p, appending its
+// translated regexp equivalent to classes and leaving a null-byte
+// placeholder in its place so the rest of the pattern can still be passed
+// through regexp.QuoteMeta (the placeholder is restored afterwards). This
+// keeps metacharacters inside a class, such as "*" in "[*?]", literal
+// instead of being expanded as glob wildcards.
+func extractClasses(p string, classes *[]string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(p); {
+		if p[i] != '[' {
+			out.WriteByte(p[i])
+			i++
+			continue
+		}
+
+		j := i + 1
+		if j < len(p) && (p[j] == '!' || p[j] == '^') {
+			j++
+		}
+		if j < len(p) && p[j] == ']' {
+			j++
+		}
+		for j < len(p) && p[j] != ']' {
+			j++
+		}
+		if j >= len(p) {
+			return "", fmt.Errorf("unterminated character class in %q", p)
+		}
+
+		body := p[i+1 : j]
+		neg := ""
+		if strings.HasPrefix(body, "!") || strings.HasPrefix(body, "^") {
+			neg = "^"
+			body = body[1:]
+		}
+
+		out.WriteString(classPlaceholder(len(*classes)))
+		*classes = append(*classes, "["+neg+regexp.QuoteMeta(body)+"]")
+		i = j + 1
+	}
+	return out.String(), nil
+}
+
 func (f *file) Func() string {
-	name := strings.TrimPrefix(f.Path, f.Prefix)
-	name = strings.TrimSuffix(name, f.Suffix)
+	name := f.strip(f.Path)
 
 	rep := func(r rune) rune {
 		if unicode.IsDigit(r) || unicode.IsLetter(r) || r > 127 {
@@ -176,44 +1107,323 @@ func (f *file) Func() string {
 	return name
 }
 
-func (f *file) pack(data []byte) ([]byte, error) {
-	o := new(bytes.Buffer)
-	w, err := flate.NewWriter(o, f.CompressLevel)
+// Name returns the slash-separated path under which this file is exposed in
+// the generated io/fs.FS, after stripping Prefix/Suffix the same way Func does.
+func (f *file) Name() (string, error) {
+	name := strings.Trim(f.strip(f.Path), "/")
+
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("invalid fs.FS name %q derived from %q", name, f.Path)
+	}
+	return name, nil
+}
+
+// BaseName is the leaf element of Name, as required by fs.FileInfo.Name.
+func (f *file) BaseName() (string, error) {
+	name, err := f.Name()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	_, err = w.Write(data)
+	return path.Base(name), nil
+}
+
+func (f *file) stat() (os.FileInfo, error) {
+	if f.info == nil {
+		info, err := os.Stat(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		f.info = info
+	}
+	return f.info, nil
+}
+
+// Size is the uncompressed size of the file, captured at generation time.
+func (f *file) Size() (int64, error) {
+	info, err := f.stat()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	if err := w.Close(); err != nil {
-		return nil, err
+	return info.Size(), nil
+}
+
+// ModTime is the file's modification time as a Unix timestamp, captured at
+// generation time.
+func (f *file) ModTime() (int64, error) {
+	info, err := f.stat()
+	if err != nil {
+		return 0, err
 	}
-	return o.Bytes(), nil
+	return info.ModTime().Unix(), nil
 }
 
-func (f *file) data() ([]byte, error) {
+// Sha256 is the SHA-256 hex digest of the file's original, uncompressed
+// content, captured at generation time.
+func (f *file) Sha256() (string, error) {
 	data, err := ioutil.ReadFile(f.Path)
 	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Sum is the bundle-wide content hash, computed the same way as
+// golang.org/x/mod/sumdb/dirhash.Hash1: the SHA-256 of the
+// newline-joined "<sha256hex>  <name>" lines for every embedded file,
+// sorted by name.
+func (c *config) Sum() (string, error) {
+	files, err := c.Files()
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, len(files))
+	sums := make(map[string]string, len(files))
+	for i := range files {
+		f := &files[i]
+		name, err := f.Name()
+		if err != nil {
+			return "", err
+		}
+		sum, err := f.Sha256()
+		if err != nil {
+			return "", err
+		}
+		names[i] = name
+		sums[name] = sum
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s  %s\n", sums[name], name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// codecKind is the generator's internal view of a Codec: which of the
+// generated package's Codec constants it corresponds to, and whether this
+// generator actually knows how to encode with it.
+type codecKind int
+
+const (
+	codecNone codecKind = iota
+	codecFlate
+	codecGzip
+	codecZstd
+	codecSnappy
+	codecS2
+	codecBrotli
+)
+
+func parseCodecKind(name string) (codecKind, error) {
+	switch name {
+	case "none":
+		return codecNone, nil
+	case "flate":
+		return codecFlate, nil
+	case "gzip":
+		return codecGzip, nil
+	case "zstd":
+		return codecZstd, nil
+	case "snappy":
+		return codecSnappy, nil
+	case "s2":
+		return codecS2, nil
+	case "brotli":
+		return codecBrotli, nil
+	default:
+		return 0, fmt.Errorf("unknown codec %q", name)
+	}
+}
+
+// Const is the identifier of the matching Codec constant in generated code.
+func (k codecKind) Const() string {
+	switch k {
+	case codecFlate:
+		return "CodecFlate"
+	case codecGzip:
+		return "CodecGzip"
+	case codecZstd:
+		return "CodecZstd"
+	case codecSnappy:
+		return "CodecSnappy"
+	case codecS2:
+		return "CodecS2"
+	case codecBrotli:
+		return "CodecBrotli"
+	default:
+		return "CodecNone"
+	}
+}
+
+// encodable reports whether this generator has a dependency-free encoder for
+// k. zstd, snappy, s2 and brotli are valid Codec IDs a consumer can decode
+// via RegisterCodec, but this generator can't produce them itself.
+func (k codecKind) encodable() bool {
+	switch k {
+	case codecNone, codecFlate, codecGzip:
+		return true
+	default:
+		return false
+	}
+}
+
+func encodeWith(k codecKind, data []byte, level int) ([]byte, error) {
+	switch k {
+	case codecNone:
+		return data, nil
+	case codecFlate:
+		buf := new(bytes.Buffer)
+		w, err := flate.NewWriter(buf, level)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case codecGzip:
+		buf := new(bytes.Buffer)
+		w, err := gzip.NewWriterLevel(buf, level)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("datacode: no encoder for codec %q", k.Const())
+	}
+}
+
+// codecKinds is the set of codecs to try for every file: codecNone plus
+// whatever -codec named (or, with no -codec, codecFlate/codecNone
+// translated from the legacy -compress flag).
+func (c *config) codecKinds() ([]codecKind, error) {
+	names := c.Codecs
+	if len(names) == 0 {
+		if c.Compress {
+			names = []string{"flate"}
+		} else {
+			names = nil
+		}
+	}
+
+	kinds := []codecKind{codecNone}
+	seen := map[codecKind]bool{codecNone: true}
+	for _, name := range names {
+		k, err := parseCodecKind(name)
+		if err != nil {
+			return nil, err
+		}
+		if !k.encodable() {
+			return nil, fmt.Errorf("-codec %q: this generator has no built-in encoder for it (only none, flate, gzip); it can still be decoded at runtime via RegisterCodec", name)
+		}
+		if !seen[k] {
+			seen[k] = true
+			kinds = append(kinds, k)
+		}
+	}
+	return kinds, nil
+}
+
+// packedResult is the smallest encoding of a file's contents found among
+// the candidate codecs, along with which codec produced it.
+type packedResult struct {
+	kind codecKind
+	data []byte
+}
+
+func (f *file) pack() (*packedResult, error) {
+	if f.packed != nil {
+		return f.packed, nil
+	}
+
+	raw, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds, err := f.codecKinds()
+	if err != nil {
+		return nil, err
+	}
+
+	best := &packedResult{kind: kinds[0]}
+	if best.data, err = encodeWith(kinds[0], raw, f.CompressLevel); err != nil {
 		return nil, err
 	}
-	if f.Compress {
-		if data, err = f.pack(data); err != nil {
+	for _, k := range kinds[1:] {
+		data, err := encodeWith(k, raw, f.CompressLevel)
+		if err != nil {
 			return nil, err
 		}
+		if len(data) < len(best.data) {
+			best = &packedResult{kind: k, data: data}
+		}
+	}
+
+	f.packed = best
+	return f.packed, nil
+}
+
+// Codec is the identifier, in generated source, of the Codec constant this
+// file was packed with.
+func (f *file) Codec() (string, error) {
+	p, err := f.pack()
+	if err != nil {
+		return "", err
 	}
-	return data, nil
+	return p.kind.Const(), nil
 }
 
+// Raw is the file's packed content as a \xXX-escaped string literal,
+// computed once and cached: shardFiles already calls this to bucket files
+// by literal size, and the shard template calls it again to emit them.
 func (f *file) Raw() (string, error) {
-	data, err := f.data()
+	if f.raw != nil {
+		return *f.raw, nil
+	}
+	p, err := f.pack()
 	if err != nil {
 		return "", err
 	}
 	out := new(bytes.Buffer)
-	for _, b := range data {
+	for _, b := range p.data {
 		fmt.Fprintf(out, "\\x%.2x", b)
 	}
-	fmt.Println(out.String())
-	return out.String(), nil
+	raw := out.String()
+	f.raw = &raw
+	return raw, nil
+}
+
+// usesCodec reports whether any embedded file was packed with k, so the
+// template only imports compress/flate or compress/gzip when needed.
+func (c *config) usesCodec(k codecKind) (bool, error) {
+	files, err := c.Files()
+	if err != nil {
+		return false, err
+	}
+	for i := range files {
+		p, err := files[i].pack()
+		if err != nil {
+			return false, err
+		}
+		if p.kind == k {
+			return true, nil
+		}
+	}
+	return false, nil
 }
+
+func (c *config) UsesFlate() (bool, error) { return c.usesCodec(codecFlate) }
+func (c *config) UsesGzip() (bool, error)  { return c.usesCodec(codecGzip) }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGenerateStreamingAccessorsBuild covers the default (-bytes=true) case,
+// where both the streaming XxxReader/XxxSize form and the buffered Xxx()
+// accessor are generated, and the -bytes=false case, where only the
+// streaming form is generated. Both should compile.
+func TestGenerateStreamingAccessorsBuild(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bytesFlag := range []bool{true, false} {
+		bytesFlag := bytesFlag
+		t.Run(map[bool]string{true: "bytes=true", false: "bytes=false"}[bytesFlag], func(t *testing.T) {
+			c := &config{
+				Package: "p",
+				Prefix:  src + "/",
+				Args:    []string{filepath.Join(src, "a.txt")},
+				Bytes:   bytesFlag,
+				Out:     "data.go",
+			}
+			out := t.TempDir()
+			buildGenerated(t, c, out)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerateMultiCodecBuilds covers -codec smallest-wins selection: a
+// bundle with more than one candidate codec (plus the implicit codecNone)
+// should still render a single, compiling package regardless of which
+// codec ends up smallest per file.
+func TestGenerateMultiCodecBuilds(t *testing.T) {
+	src := t.TempDir()
+	// Highly compressible content so flate/gzip actually win over none,
+	// and incompressible content so none wins instead -- exercising both
+	// branches of the smallest-wins choice in the same bundle.
+	files := map[string]string{
+		"compressible.txt":   strings.Repeat("aaaaaaaaaa", 200),
+		"incompressible.txt": "\x01\x02\x03\x04\x05",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &config{
+		Package: "p",
+		Prefix:  src + "/",
+		Args:    []string{filepath.Join(src, "compressible.txt"), filepath.Join(src, "incompressible.txt")},
+		Codecs:  []string{"flate", "gzip"},
+		Out:     "data.go",
+	}
+
+	got, err := c.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range got {
+		packed, err := f.pack()
+		if err != nil {
+			t.Fatalf("pack %s: %v", f.Path, err)
+		}
+		if len(packed.data) >= len(files[filepath.Base(f.Path)]) && packed.kind != codecNone {
+			t.Errorf("%s: codec %s didn't beat codecNone but was chosen anyway", f.Path, packed.kind.Const())
+		}
+	}
+
+	out := t.TempDir()
+	buildGenerated(t, c, out)
+}
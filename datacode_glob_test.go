@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeAssets(t *testing.T, dir string) {
+	t.Helper()
+	for name, content := range map[string]string{
+		"assets/keep1.txt":      "one",
+		"assets/keep2.txt":      "two",
+		"assets/skip.txt":       "three",
+		"assets/sub/nested.txt": "four",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestGenerateRecursiveDirBuilds covers plain directory embedding: passing
+// a directory as an arg should pull in every file beneath it, including
+// nested ones, and the result should compile.
+func TestGenerateRecursiveDirBuilds(t *testing.T) {
+	src := t.TempDir()
+	writeAssets(t, src)
+
+	c := &config{
+		Package: "p",
+		Prefix:  src + "/",
+		Args:    []string{filepath.Join(src, "assets")},
+		Out:     "data.go",
+	}
+	files, err := c.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("got %d files, want 4 (recursive walk should include assets/sub/nested.txt)", len(files))
+	}
+
+	out := t.TempDir()
+	buildGenerated(t, c, out)
+}
+
+// TestGenerateDoublestarGlobBuilds covers a "**/*.txt" doublestar pattern
+// passed directly as an arg: it should match files at any depth under
+// assets/ and the result should compile.
+func TestGenerateDoublestarGlobBuilds(t *testing.T) {
+	src := t.TempDir()
+	writeAssets(t, src)
+
+	c := &config{
+		Package: "p",
+		Prefix:  src + "/",
+		Args:    []string{filepath.Join(src, "assets", "**", "*.txt")},
+		Out:     "data.go",
+	}
+	files, err := c.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("got %d files, want 4 (** should reach assets/sub/nested.txt too)", len(files))
+	}
+
+	out := t.TempDir()
+	buildGenerated(t, c, out)
+}
+
+// TestGenerateBracketClassGlobBuilds covers a "[...]" character-class glob
+// passed directly as an arg: it should match only keep1.txt/keep2.txt, not
+// skip.txt, and the result should compile. This is a regression test for a
+// bug where globToRegexp never translated "[...]" and so such a pattern
+// silently matched zero files.
+func TestGenerateBracketClassGlobBuilds(t *testing.T) {
+	src := t.TempDir()
+	writeAssets(t, src)
+
+	c := &config{
+		Package: "p",
+		Prefix:  src + "/",
+		Args:    []string{filepath.Join(src, "assets", "keep[12].txt")},
+		Out:     "data.go",
+	}
+	files, err := c.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2 (keep[12].txt should match keep1.txt and keep2.txt only)", len(files))
+	}
+
+	out := t.TempDir()
+	buildGenerated(t, c, out)
+}
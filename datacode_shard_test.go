@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerateShardedBundleBuilds covers -max-file-size sharding: a bundle
+// whose raw literals don't fit in one shard should render a shared glue
+// file plus one shard file per group, and the whole set should compile
+// together.
+func TestGenerateShardedBundleBuilds(t *testing.T) {
+	src := t.TempDir()
+	for i, content := range []string{
+		strings.Repeat("a", 100),
+		strings.Repeat("b", 100),
+		strings.Repeat("c", 100),
+	} {
+		name := filepath.Join(src, "file"+string(rune('1'+i))+".txt")
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &config{
+		Package:     "p",
+		Prefix:      src + "/",
+		Args:        []string{src},
+		MaxFileSize: 150,
+		Out:         "data.go",
+	}
+
+	shards, err := c.shardFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) < 2 {
+		t.Fatalf("got %d shard(s), want at least 2 for a bundle exceeding -max-file-size", len(shards))
+	}
+
+	out := t.TempDir()
+	buildGenerated(t, c, out)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// dirhash1 reimplements golang.org/x/mod/sumdb/dirhash.Hash1 straight from
+// its spec (sort file names, hash the "<sha256hex>  <name>\n" lines in that
+// order), independent of config.Sum, so a regression there can't also creep
+// into the expected value.
+func dirhash1(files map[string]string) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		sum := sha256.Sum256([]byte(files[name]))
+		fmt.Fprintf(h, "%x  %s\n", sum, name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func TestConfigSumSortsByName(t *testing.T) {
+	dir := t.TempDir()
+	// Chosen so the line "<sha256hex>  b.txt" sorts before the line for
+	// a.txt even though "a.txt" < "b.txt" by name, catching a regression
+	// to sorting the rendered lines instead of the file names.
+	files := map[string]string{
+		"a.txt": "x0",
+		"b.txt": "y1",
+	}
+	var args []string
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, p)
+	}
+
+	c := &config{Args: args, Prefix: dir + "/"}
+	got, err := c.Sum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := dirhash1(files); got != want {
+		t.Errorf("Sum() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// buildGenerated renders c with doIt, writes the resulting file(s) into dir
+// and runs "go build" against dir, failing the test if the bundle doesn't
+// compile. It exists so bugs in the generator that only show up in the
+// generated code (an unused import, a glob match that silently embeds the
+// wrong set of files, ...) get caught the same way a real consumer would
+// hit them.
+func buildGenerated(t *testing.T, c *config, dir string) {
+	t.Helper()
+
+	outs, err := doIt(c, true)
+	if err != nil {
+		t.Fatalf("doIt: %v", err)
+	}
+	for _, o := range outs {
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(o.Name)), o.Data, 0644); err != nil {
+			t.Fatalf("write %s: %v", o.Name, err)
+		}
+	}
+
+	cmd := exec.Command("go", "build", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GO111MODULE=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build %s: %v\n%s", dir, err, out)
+	}
+}